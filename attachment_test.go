@@ -0,0 +1,39 @@
+package mandrill
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestAddAttachmentReader(t *testing.T) {
+	m := Message{}
+	err := m.AddAttachmentReader(strings.NewReader("testData"), "testName", "testMime")
+	ensure.Nil(t, err)
+	content, err := base64.StdEncoding.DecodeString(m.Attachments[0].Content)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, []byte("testData"), content)
+}
+
+func TestAddInlineImage(t *testing.T) {
+	m := Message{}
+	err := m.AddInlineImage(strings.NewReader("testImageData"), "testCID", "image/png")
+	ensure.Nil(t, err)
+	content, err := base64.StdEncoding.DecodeString(m.Images[0].Content)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, []byte("testImageData"), content)
+	ensure.DeepEqual(t, m.Images[0].Name, "testCID")
+}
+
+func TestAddAttachmentReaderTooLarge(t *testing.T) {
+	old := MaxAttachmentBytes
+	MaxAttachmentBytes = 4
+	defer func() { MaxAttachmentBytes = old }()
+
+	m := Message{}
+	err := m.AddAttachmentReader(bytes.NewReader([]byte("too much data")), "testName", "testMime")
+	ensure.DeepEqual(t, err, ErrAttachmentTooLarge)
+}
@@ -0,0 +1,126 @@
+package mandrill
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DefaultBatchSize is the chunk size SendBatched uses when batchSize <= 0,
+// matching the MaxNumberOfRecipients cap other transactional APIs enforce.
+const DefaultBatchSize = 1000
+
+// defaultConcurrency is how many batches SendBatched sends at once unless
+// overridden with SetConcurrency.
+const defaultConcurrency = 5
+
+// BatchFailure records that one chunk of a SendBatched call failed to send.
+type BatchFailure struct {
+	// ChunkIndex is the position of the failed chunk among the chunks
+	// SendBatched split msg.To into.
+	ChunkIndex int
+	Err        error
+}
+
+// BatchError is returned by SendBatched when one or more chunks failed to
+// send. The results from chunks that did succeed are still returned
+// alongside it, so callers can retry just the failed chunks.
+type BatchError struct {
+	Failures []*BatchFailure
+	// Total is the number of chunks msg.To was split into.
+	Total int
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("mandrill: %d of %d batches failed to send", len(e.Failures), e.Total)
+}
+
+// SendBatched sends msg in chunks of at most batchSize recipients (or
+// DefaultBatchSize if batchSize <= 0), issuing the chunks concurrently with
+// a bounded worker pool (see SetConcurrency). Per-recipient RecipientMetadata
+// is preserved and filtered to match each chunk. Results are concatenated in
+// input order. If any chunk fails, the results collected from the chunks
+// that succeeded are returned along with a *BatchError describing which
+// chunks failed and why.
+func (msg *Message) SendBatched(async bool, batchSize int, opts ...Option) ([]*SendResult, error) {
+	o := &options{url: apiBase, concurrency: defaultConcurrency}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if o.concurrency <= 0 {
+		o.concurrency = defaultConcurrency
+	}
+
+	chunks := msg.chunkRecipients(batchSize)
+	results := make([][]*SendResult, len(chunks))
+	errs := make([]error, len(chunks))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, o.concurrency)
+	for i, chunk := range chunks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, chunk *Message) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], errs[i] = chunk.Send(async, opts...)
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	var all []*SendResult
+	var failures []*BatchFailure
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, &BatchFailure{ChunkIndex: i, Err: err})
+			continue
+		}
+		all = append(all, results[i]...)
+	}
+	if len(failures) > 0 {
+		return all, &BatchError{Failures: failures, Total: len(chunks)}
+	}
+	return all, nil
+}
+
+// chunkRecipients splits msg into chunks of at most batchSize recipients
+// each. Every chunk is a shallow copy of msg with To (and RecipientMetadata,
+// filtered to match) replaced.
+func (msg *Message) chunkRecipients(batchSize int) []*Message {
+	var chunks []*Message
+	for i := 0; i < len(msg.To); i += batchSize {
+		end := i + batchSize
+		if end > len(msg.To) {
+			end = len(msg.To)
+		}
+		to := msg.To[i:end]
+
+		chunk := *msg
+		chunk.To = to
+		chunk.RecipientMetadata = filterRecipientMetadata(msg.RecipientMetadata, to)
+		chunks = append(chunks, &chunk)
+	}
+	return chunks
+}
+
+// filterRecipientMetadata returns the entries of all whose Recipient is one
+// of to's email addresses.
+func filterRecipientMetadata(all []*RecipientMetadata, to []*To) []*RecipientMetadata {
+	if len(all) == 0 {
+		return nil
+	}
+	inChunk := make(map[string]bool, len(to))
+	for _, t := range to {
+		inChunk[t.Email] = true
+	}
+
+	var filtered []*RecipientMetadata
+	for _, md := range all {
+		if inChunk[md.Recipient] {
+			filtered = append(filtered, md)
+		}
+	}
+	return filtered
+}
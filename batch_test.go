@@ -0,0 +1,125 @@
+package mandrill
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+)
+
+// echoRecipientsHandler responds with one SendResult per recipient in the
+// request's message, so tests can assert on chunking behavior instead of a
+// fixed canned response.
+func echoRecipientsHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Message struct {
+			To []*To `json:"to"`
+		} `json:"message"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	res := make([]*SendResult, len(body.Message.To))
+	for i, to := range body.Message.To {
+		res[i] = &SendResult{Email: to.Email, Status: "sent"}
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+func TestSendBatched(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(echoRecipientsHandler))
+	defer srv.Close()
+
+	m := Message{FromEmail: "test@email.com", Subject: "Test"}
+	for i := 0; i < 5; i++ {
+		m.AddRecipient(fmt.Sprintf("user%d@email.com", i), "test user")
+	}
+
+	res, err := m.SendBatched(false, 2, SetMessageUrl(srv.URL))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(res), 5)
+
+	gotEmails := make(map[string]bool, len(res))
+	for _, r := range res {
+		gotEmails[r.Email] = true
+	}
+	for i := 0; i < 5; i++ {
+		ensure.True(t, gotEmails[fmt.Sprintf("user%d@email.com", i)])
+	}
+}
+
+func TestChunkRecipientsPreservesMetadata(t *testing.T) {
+	m := Message{}
+	for i := 0; i < 3; i++ {
+		email := fmt.Sprintf("user%d@email.com", i)
+		m.AddRecipient(email, "test user")
+		m.AddRecipientMetadata(email, map[string]interface{}{"n": i})
+	}
+
+	chunks := m.chunkRecipients(2)
+	ensure.DeepEqual(t, len(chunks), 2)
+	ensure.DeepEqual(t, len(chunks[0].To), 2)
+	ensure.DeepEqual(t, len(chunks[0].RecipientMetadata), 2)
+	ensure.DeepEqual(t, len(chunks[1].To), 1)
+	ensure.DeepEqual(t, len(chunks[1].RecipientMetadata), 1)
+	ensure.DeepEqual(t, chunks[1].RecipientMetadata[0].Recipient, "user2@email.com")
+}
+
+func TestSendBatchedZeroConcurrencyFallsBackToDefault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(echoRecipientsHandler))
+	defer srv.Close()
+
+	m := Message{FromEmail: "test@email.com", Subject: "Test"}
+	for i := 0; i < 3; i++ {
+		m.AddRecipient(fmt.Sprintf("user%d@email.com", i), "test user")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		res, err := m.SendBatched(false, 1, SetMessageUrl(srv.URL), SetConcurrency(0))
+		ensure.Nil(t, err)
+		ensure.DeepEqual(t, len(res), 3)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("SendBatched with SetConcurrency(0) did not return; likely deadlocked")
+	}
+}
+
+func TestSendBatchedPartialFailure(t *testing.T) {
+	// The chunk addressed to user1 fails; the chunk addressed to user0 is
+	// echoed back as sent.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if bytes.Contains(body, []byte("user1@email.com")) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`not json`))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		echoRecipientsHandler(w, r)
+	}))
+	defer srv.Close()
+
+	m := Message{FromEmail: "test@email.com", Subject: "Test"}
+	m.AddRecipient("user0@email.com", "test user")
+	m.AddRecipient("user1@email.com", "test user")
+
+	res, err := m.SendBatched(false, 1, SetMessageUrl(srv.URL), SetRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	ensure.NotNil(t, err)
+	batchErr, ok := err.(*BatchError)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, batchErr.Total, 2)
+	ensure.DeepEqual(t, len(batchErr.Failures), 1)
+	ensure.DeepEqual(t, batchErr.Failures[0].ChunkIndex, 1)
+	ensure.DeepEqual(t, len(res), 1)
+	ensure.DeepEqual(t, res[0].Email, "user0@email.com")
+}
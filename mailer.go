@@ -0,0 +1,45 @@
+package mandrill
+
+import "context"
+
+// Mailer is implemented by types that can deliver a Message through some
+// transport. The package-level Send/SendTemplate functions on Message always
+// go through Mandrill's API; Mailer lets callers swap in an alternate
+// transport, such as SMTPMailer, typically to fail over when Mandrill itself
+// is unreachable.
+type Mailer interface {
+	Send(msg *Message, async bool) ([]*SendResult, error)
+	SendTemplate(msg *Message, tmpl string, content map[string]string, async bool) ([]*SendResult, error)
+}
+
+// MandrillMailer is a Mailer that delivers through Mandrill's HTTP API. It is
+// a thin wrapper around Message.Send/SendTemplate, useful when code is
+// written against the Mailer interface instead of calling Message's methods
+// directly.
+type MandrillMailer struct {
+	// Key is the API key to authenticate with. If empty, the package-level
+	// Key is used instead.
+	Key string
+}
+
+// NewMandrillMailer returns a MandrillMailer that authenticates with key.
+func NewMandrillMailer(key string) *MandrillMailer {
+	return &MandrillMailer{Key: key}
+}
+
+func (c *MandrillMailer) key() string {
+	if c.Key != "" {
+		return c.Key
+	}
+	return Key
+}
+
+// Send implements Mailer.
+func (c *MandrillMailer) Send(msg *Message, async bool) ([]*SendResult, error) {
+	return sendMessage(context.Background(), c.key(), msg, async)
+}
+
+// SendTemplate implements Mailer.
+func (c *MandrillMailer) SendTemplate(msg *Message, tmpl string, content map[string]string, async bool) ([]*SendResult, error) {
+	return sendTemplateMessage(context.Background(), c.key(), msg, tmpl, content, async)
+}
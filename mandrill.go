@@ -25,25 +25,119 @@
 // It's even easier to send a message using a template:
 //
 //     res, err := mandrill.NewMessageTo(email, name).SendTemplate(tmplName, data, false)
+//
+// Message.Send and Message.SendTemplate always go through Mandrill's API. To
+// swap in another transport, such as a fallback SMTP server, write against
+// the Mailer interface instead and use SMTPMailer or MandrillMailer.
 package mandrill
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/jmcvetta/napping"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
 )
 
+// sendAtLayout is the format Mandrill expects for the "send_at" field: UTC,
+// "YYYY-MM-DD HH:MM:SS".
+const sendAtLayout = "2006-01-02 15:04:05"
+
 // API key for Mandrill user. You should set this to your API key before calling
 // any of the functions. You can get a API key for your account in your
 // Mandrill account settings.
 var Key string
 
+// apiBase is the root of Mandrill's API, used unless overridden with
+// SetMessageUrl.
+const apiBase = "https://mandrillapp.com/api/1.0"
+
+// options holds per-request overrides applied by Option functions.
+type options struct {
+	url         string
+	concurrency int
+	httpClient  *http.Client
+	retryPolicy RetryPolicy
+}
+
+// Option customizes a single API request.
+type Option func(*options)
+
+// SetMessageUrl overrides the base URL a request is sent to instead of
+// Mandrill's own API. It is mainly useful in tests, so the transport can be
+// pointed at an httptest.Server instead of the real Mandrill endpoint.
+func SetMessageUrl(url string) Option {
+	return func(o *options) {
+		o.url = url
+	}
+}
+
+// SetConcurrency bounds how many requests SendBatched has in flight at once.
+// It defaults to 5 and has no effect outside of SendBatched.
+func SetConcurrency(n int) Option {
+	return func(o *options) {
+		o.concurrency = n
+	}
+}
+
+// HTTPClient overrides the *http.Client used for a request, for example to
+// set a custom timeout, route through a proxy, or add instrumentation.
+func HTTPClient(c *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = c
+	}
+}
+
+// SetRetryPolicy overrides the RetryPolicy used for a request.
+func SetRetryPolicy(p RetryPolicy) Option {
+	return func(o *options) {
+		o.retryPolicy = p
+	}
+}
+
+// RetryPolicy controls how do retries a request that failed with a network
+// error, a 5xx response, or a 429 response. The nth retry waits BaseDelay *
+// 2^(n-1), plus up to Jitter of random extra delay, unless the
+// response carried a Retry-After header, in which case that is honored
+// instead.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	Jitter      time.Duration
+}
+
+// DefaultRetryPolicy is used for requests that don't override it with
+// SetRetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   200 * time.Millisecond,
+	Jitter:      100 * time.Millisecond,
+}
+
 // type Error holds error return messages from API calls.
 type Error struct {
 	Status  string `json:"status"`
 	Code    int    `json:"code"`
 	Name    string `json:"name"`
 	Message string `json:"message"`
+
+	// StatusCode is the HTTP status code of the response that produced this
+	// error.
+	StatusCode int `json:"-"`
+	// RateLimit and RateLimitRemaining surface Mandrill's
+	// X-RateLimit-Limit/X-RateLimit-Remaining response headers, when Mandrill
+	// sent them.
+	RateLimit          int `json:"-"`
+	RateLimitRemaining int `json:"-"`
+	// RetryAfter surfaces the Retry-After header on a 429 response, when
+	// Mandrill sent one.
+	RetryAfter time.Duration `json:"-"`
 }
 
 // newError returns a new Error instance.
@@ -56,44 +150,144 @@ func (err *Error) Error() string {
 	return fmt.Sprintf("mandrill: %s: %s", err.Name, err.Message)
 }
 
-// do is an easy function for performing requests against Mandrill's API.
-func do(url string, data interface{}, result interface{}) error {
-	// merr can store a the JSON object returned by mandrill on errors
-	merr := newError()
-	// prepare and send the request
-	rr := &napping.Request{
-		Url:     "https://mandrillapp.com/api/1.0" + url,
-		Method:  "POST",
-		Payload: data,
-		Result:  result,
-		Error:   merr}
-	res, err := napping.Send(rr)
-
-	// network error
+// isRetryable reports whether a response with the given status code is
+// worth retrying: network errors never reach here, so this only covers
+// server errors and rate limiting.
+func isRetryable(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// retryDelay picks how long to wait before the given attempt (1-indexed,
+// where attempt 2 is the first retry), honoring retryAfter if the previous
+// response carried one.
+func retryDelay(p RetryPolicy, attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	delay := p.BaseDelay * time.Duration(uint(1)<<uint(attempt-2))
+	if p.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(p.Jitter)))
+	}
+	return delay
+}
+
+// do is an easy function for performing requests against Mandrill's API. It
+// retries failures per o.retryPolicy, tying the whole attempt loop to ctx so
+// a caller's cancellation or deadline is respected between retries.
+func do(ctx context.Context, url string, data interface{}, result interface{}, opts ...Option) error {
+	o := &options{
+		url:         apiBase,
+		concurrency: defaultConcurrency,
+		httpClient:  http.DefaultClient,
+		retryPolicy: DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	body, err := json.Marshal(data)
 	if err != nil {
 		return err
 	}
-	// mandrill error
-	if res.Status() != 200 {
-		if merr != nil {
+
+	var lastErr error
+	var retryAfter time.Duration
+	for attempt := 1; attempt <= o.retryPolicy.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(retryDelay(o.retryPolicy, attempt, retryAfter)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", o.url+url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := o.httpClient.Do(req)
+		if err != nil {
+			lastErr = err
+			retryAfter = 0
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			continue
+		}
+
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			retryAfter = 0
+			continue
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			if result != nil && len(respBody) > 0 {
+				return json.Unmarshal(respBody, result)
+			}
+			return nil
+		}
+
+		merr := newError()
+		json.Unmarshal(respBody, merr)
+		merr.StatusCode = resp.StatusCode
+		merr.RateLimit, merr.RateLimitRemaining = rateLimitFromHeader(resp.Header)
+		merr.RetryAfter = retryAfterFromHeader(resp.Header)
+
+		if !isRetryable(resp.StatusCode) {
 			return merr
-		} else {
-			// a return JSON was not found/parsed
-			fmt.Errorf("mandrill: unknown error happened")
 		}
+		lastErr = merr
+		retryAfter = merr.RetryAfter
 	}
-	// no error happened
-	return nil
+	return lastErr
+}
+
+// Do performs a request against path on Mandrill's API, the same way every
+// function in this package does: retried per RetryPolicy and tied to ctx, with
+// rate-limit and Retry-After information surfaced on the returned *Error. It
+// is exported so other mandrill packages, like webhook, can reuse this
+// request machinery instead of rolling their own client.
+func Do(ctx context.Context, path string, data, result interface{}, opts ...Option) error {
+	return do(ctx, path, data, result, opts...)
+}
+
+// rateLimitFromHeader reads Mandrill's X-RateLimit-Limit and
+// X-RateLimit-Remaining response headers, if present.
+func rateLimitFromHeader(h http.Header) (limit, remaining int) {
+	limit, _ = strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	remaining, _ = strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	return limit, remaining
+}
+
+// retryAfterFromHeader reads a Retry-After response header expressed in
+// seconds, as Mandrill sends it on 429 responses.
+func retryAfterFromHeader(h http.Header) time.Duration {
+	secs, err := strconv.Atoi(h.Get("Retry-After"))
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
 }
 
 // Ping validates your API key. Call this to make sure your API key is correct.
 // It should return nil as error if everything is OK.
-func Ping() error {
+func Ping(opts ...Option) error {
+	return ContextPing(context.Background(), opts...)
+}
+
+// ContextPing is like Ping, but ties the request to ctx so cancellation and
+// deadlines propagate.
+func ContextPing(ctx context.Context, opts ...Option) error {
 	var data struct {
 		Key string `json:"key"`
 	}
 	data.Key = Key
-	return do("/users/ping", &data, nil)
+	return do(ctx, "/users/ping", &data, nil, opts...)
 }
 
 // Type SendResult holds information returned by send requests.
@@ -165,10 +359,39 @@ type Message struct {
 	SubAccount string `json:"subaccount,omitempty"`
 	// attachments
 	Attachments []*Attachment `json:"attachments,omitempty"`
+	// inline images, referenced from HTML via <img src="cid:...">; Mandrill
+	// treats these separately from Attachments
+	Images []*Attachment `json:"images,omitempty"`
 	// optional extra headers to add to the message (most headers are allowed)
 	Headers map[string]string `json:"headers,omitempty"`
 	// merge language to be used (can be mailchimp or handlebars)
 	MergeLanguage string `json:"merge_language,omitempty"`
+	// whether to turn on open tracking for this message
+	TrackOpens *bool `json:"track_opens,omitempty"`
+	// whether to turn on click tracking for this message
+	TrackClicks *bool `json:"track_clicks,omitempty"`
+	// whether to automatically generate a text part for messages that only
+	// have an HTML part
+	AutoText *bool `json:"auto_text,omitempty"`
+	// whether to automatically generate an HTML part for messages that only
+	// have a text part
+	AutoHTML *bool `json:"auto_html,omitempty"`
+	// whether to keep To, Cc, and Bcc recipients visible to each other
+	PreserveRecipients *bool `json:"preserve_recipients,omitempty"`
+	// a custom domain to use for the messages's return-path
+	ReturnPathDomain string `json:"return_path_domain,omitempty"`
+	// a custom domain to use for DKIM signing
+	SigningDomain string `json:"signing_domain,omitempty"`
+	// whether this message is important, bypassing normal sending throttling
+	Important bool `json:"important,omitempty"`
+	// SendAt schedules the message for delivery at a later time. It is sent
+	// as a sibling of the message in the request, not a field inside it, so
+	// it is excluded from the Message's own JSON; see ScheduleAt.
+	SendAt time.Time `json:"-"`
+	// IPPool selects the dedicated IP pool to send the message through. Like
+	// SendAt, it travels as a sibling of the message in the request; see
+	// UseIPPool.
+	IPPool string `json:"-"`
 	// TODO implement other fields
 }
 
@@ -244,6 +467,66 @@ func (msg *Message) AddAttachment(data []byte, name, mime string) *Message {
 	return msg
 }
 
+// MaxAttachmentBytes caps how much data AddAttachmentReader and
+// AddInlineImage will read from a single attachment, so a reader never has
+// to be fully buffered beyond what Mandrill would accept anyway. It defaults
+// to 25 MiB; readers producing more return ErrAttachmentTooLarge.
+var MaxAttachmentBytes int64 = 25 * 1024 * 1024
+
+// ErrAttachmentTooLarge is returned by AddAttachmentReader and AddInlineImage
+// when a reader produces more than MaxAttachmentBytes of data.
+var ErrAttachmentTooLarge = errors.New("mandrill: attachment exceeds MaxAttachmentBytes")
+
+// AddAttachmentReader adds an attachment to be sent via Mandrill, streaming
+// its content from r through a base64 encoder instead of requiring it as a
+// byte slice first, so large files or *os.File handles can be attached
+// directly. The encoded content is held in memory on msg. At most
+// MaxAttachmentBytes are read from r.
+func (msg *Message) AddAttachmentReader(r io.Reader, name, mime string) error {
+	content, err := readAttachment(r)
+	if err != nil {
+		return err
+	}
+	msg.Attachments = append(msg.Attachments, &Attachment{
+		Mime:    mime,
+		Name:    name,
+		Content: content,
+	})
+	return nil
+}
+
+// AddInlineImage adds an inline image to msg, streamed from r like
+// AddAttachmentReader, for use from HTML via <img src="cid:cid">.
+func (msg *Message) AddInlineImage(r io.Reader, cid, mime string) error {
+	content, err := readAttachment(r)
+	if err != nil {
+		return err
+	}
+	msg.Images = append(msg.Images, &Attachment{
+		Mime:    mime,
+		Name:    cid,
+		Content: content,
+	})
+	return nil
+}
+
+// readAttachment base64-encodes up to MaxAttachmentBytes read from r.
+func readAttachment(r io.Reader) (string, error) {
+	var buf bytes.Buffer
+	enc := base64.NewEncoder(base64.StdEncoding, &buf)
+	n, err := io.Copy(enc, io.LimitReader(r, MaxAttachmentBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	if n > MaxAttachmentBytes {
+		return "", ErrAttachmentTooLarge
+	}
+	return buf.String(), nil
+}
+
 // AddHeader adds a header to a message
 func (msg *Message) AddHeader(name, value string) *Message {
 	if msg.Headers == nil {
@@ -253,29 +536,76 @@ func (msg *Message) AddHeader(name, value string) *Message {
 	return msg
 }
 
-// Send performs a send request for msg.
-func (msg *Message) Send(async bool) ([]*SendResult, error) {
+// ScheduleAt schedules msg to be sent at t instead of immediately.
+func (msg *Message) ScheduleAt(t time.Time) *Message {
+	msg.SendAt = t
+	return msg
+}
+
+// SetTracking turns open and click tracking on or off for msg.
+func (msg *Message) SetTracking(opens, clicks bool) *Message {
+	msg.TrackOpens = &opens
+	msg.TrackClicks = &clicks
+	return msg
+}
+
+// UseIPPool sends msg through the named dedicated IP pool.
+func (msg *Message) UseIPPool(pool string) *Message {
+	msg.IPPool = pool
+	return msg
+}
+
+// Send performs a send request for msg using the package-level Key and
+// context.Background(). opts can be used to override per-request behavior,
+// such as SetMessageUrl in tests.
+func (msg *Message) Send(async bool, opts ...Option) ([]*SendResult, error) {
+	return msg.SendContext(context.Background(), async, opts...)
+}
+
+// SendContext is like Send, but ties the request (and its retries) to ctx so
+// cancellation and deadlines propagate.
+func (msg *Message) SendContext(ctx context.Context, async bool, opts ...Option) ([]*SendResult, error) {
+	return sendMessage(ctx, Key, msg, async, opts...)
+}
+
+// SendTemplate performs a template-based send request for msg using the
+// package-level Key.
+func (msg *Message) SendTemplate(tmpl string, content map[string]string, async bool, opts ...Option) ([]*SendResult, error) {
+	return sendTemplateMessage(context.Background(), Key, msg, tmpl, content, async, opts...)
+}
+
+// sendMessage performs a send request for msg, authenticated with key. It
+// backs both Message.Send and MandrillMailer.Send.
+func sendMessage(ctx context.Context, key string, msg *Message, async bool, opts ...Option) ([]*SendResult, error) {
 	// prepare request data
 	var data struct {
 		Key     string   `json:"key"`
 		Message *Message `json:"message,omitempty"`
 		Async   bool     `json:"async"`
+		SendAt  string   `json:"send_at,omitempty"`
+		IPPool  string   `json:"ip_pool,omitempty"`
 	}
-	data.Key = Key
+	data.Key = key
 	data.Message = msg
 	data.Async = async
+	if !msg.SendAt.IsZero() {
+		data.SendAt = msg.SendAt.UTC().Format(sendAtLayout)
+	}
+	data.IPPool = msg.IPPool
 
 	// perform the request
 	res := make([]*SendResult, 0)
-	err := do("/messages/send", &data, &res)
+	err := do(ctx, "/messages/send", &data, &res, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
-// SendTemplate performs a template-based send request for msg.
-func (msg *Message) SendTemplate(tmpl string, content map[string]string, async bool) ([]*SendResult, error) {
+// sendTemplateMessage performs a template-based send request for msg,
+// authenticated with key. It backs both Message.SendTemplate and
+// MandrillMailer.SendTemplate.
+func sendTemplateMessage(ctx context.Context, key string, msg *Message, tmpl string, content map[string]string, async bool, opts ...Option) ([]*SendResult, error) {
 	// prepare request data
 	var data struct {
 		Key             string      `json:"key"`
@@ -283,17 +613,23 @@ func (msg *Message) SendTemplate(tmpl string, content map[string]string, async b
 		TemplateContent []*variable `json:"template_content"`
 		Message         *Message    `json:"message,omitempty"`
 		Async           bool        `json:"async"`
+		SendAt          string      `json:"send_at,omitempty"`
+		IPPool          string      `json:"ip_pool,omitempty"`
 	}
 
-	data.Key = Key
+	data.Key = key
 	data.TemplateName = tmpl
 	data.TemplateContent = mapToStringVars(content)
 	data.Message = msg
 	data.Async = async
+	if !msg.SendAt.IsZero() {
+		data.SendAt = msg.SendAt.UTC().Format(sendAtLayout)
+	}
+	data.IPPool = msg.IPPool
 
 	// perform the request
 	res := make([]*SendResult, 0)
-	err := do("/messages/send-template", &data, &res)
+	err := do(ctx, "/messages/send-template", &data, &res, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -24,7 +24,7 @@ func TestAddRecipient(t *testing.T) {
 
 func TestAddGlobalMergeVars(t *testing.T) {
 	m := Message{}
-	gmv := make(map[string]string)
+	gmv := make(map[string]interface{})
 	gmv["testName"] = "testContent"
 	m.AddGlobalMergeVars(gmv)
 	ensure.DeepEqual(t, "testName", m.GlobalMergeVars[0].Name)
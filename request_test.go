@@ -0,0 +1,84 @@
+package mandrill
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestContextPing(t *testing.T) {
+	srv := httptest.NewServer(&testHandler{
+		respHeader: http.StatusOK,
+		respBody:   []byte(`{}`),
+	})
+	defer srv.Close()
+	err := ContextPing(context.Background(), SetMessageUrl(srv.URL))
+	ensure.Nil(t, err)
+}
+
+func TestSendContextCancellation(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	m := Message{}
+	m.AddRecipient("user@email.com", "test user")
+	_, err := m.SendContext(ctx, false, SetMessageUrl(srv.URL))
+	ensure.NotNil(t, err)
+}
+
+func TestDoRetriesOn5xxThenSucceeds(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`[{"status":"sent","email":"test@test.com"}]`))
+	}))
+	defer srv.Close()
+
+	m := Message{}
+	m.AddRecipient("user@email.com", "test user")
+	res, err := m.Send(false, SetMessageUrl(srv.URL), SetRetryPolicy(RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+	}))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(res), 1)
+	ensure.DeepEqual(t, int(atomic.LoadInt32(&attempts)), 3)
+}
+
+func TestDoSurfacesRateLimitHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "10")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"status":"error","name":"Throttled","message":"too many requests"}`))
+	}))
+	defer srv.Close()
+
+	m := Message{}
+	m.AddRecipient("user@email.com", "test user")
+	_, err := m.Send(false, SetMessageUrl(srv.URL), SetRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	ensure.NotNil(t, err)
+	merr, ok := err.(*Error)
+	ensure.True(t, ok)
+	ensure.DeepEqual(t, merr.RateLimit, 10)
+	ensure.DeepEqual(t, merr.RateLimitRemaining, 0)
+	ensure.DeepEqual(t, merr.RetryAfter, time.Second)
+}
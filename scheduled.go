@@ -0,0 +1,75 @@
+package mandrill
+
+import (
+	"context"
+	"time"
+)
+
+// ScheduledMessage describes a message that is queued for scheduled
+// delivery, as returned by ListScheduled, CancelScheduled, and
+// RescheduleScheduled.
+type ScheduledMessage struct {
+	Id         string `json:"_id"`
+	CreatedAt  string `json:"created_at"`
+	SendAt     string `json:"send_at"`
+	FromEmail  string `json:"from_email"`
+	FromName   string `json:"from_name"`
+	Subject    string `json:"subject"`
+	To         string `json:"to"`
+	SubAccount string `json:"subaccount"`
+}
+
+// ListScheduled lists messages queued for scheduled delivery to to, or every
+// scheduled message if to is empty.
+func ListScheduled(to string, opts ...Option) ([]*ScheduledMessage, error) {
+	var data struct {
+		Key string `json:"key"`
+		To  string `json:"to,omitempty"`
+	}
+	data.Key = Key
+	data.To = to
+
+	res := make([]*ScheduledMessage, 0)
+	err := do(context.Background(), "/messages/list-scheduled", &data, &res, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// CancelScheduled cancels the scheduled message with the given id.
+func CancelScheduled(id string, opts ...Option) (*ScheduledMessage, error) {
+	var data struct {
+		Key string `json:"key"`
+		Id  string `json:"id"`
+	}
+	data.Key = Key
+	data.Id = id
+
+	res := &ScheduledMessage{}
+	err := do(context.Background(), "/messages/cancel-scheduled", &data, res, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RescheduleScheduled moves the scheduled message with the given id to send
+// at sendAt instead.
+func RescheduleScheduled(id string, sendAt time.Time, opts ...Option) (*ScheduledMessage, error) {
+	var data struct {
+		Key    string `json:"key"`
+		Id     string `json:"id"`
+		SendAt string `json:"send_at"`
+	}
+	data.Key = Key
+	data.Id = id
+	data.SendAt = sendAt.UTC().Format(sendAtLayout)
+
+	res := &ScheduledMessage{}
+	err := do(context.Background(), "/messages/reschedule-scheduled", &data, res, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,117 @@
+package mandrill
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestListScheduled(t *testing.T) {
+	srv := httptest.NewServer(&testHandler{
+		respHeader: http.StatusOK,
+		respBody:   []byte(`[{"_id":"abc123","to":"user@email.com"}]`),
+	})
+	defer srv.Close()
+
+	res, err := ListScheduled("user@email.com", SetMessageUrl(srv.URL))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(res), 1)
+	ensure.DeepEqual(t, res[0].Id, "abc123")
+	ensure.DeepEqual(t, res[0].To, "user@email.com")
+}
+
+func TestCancelScheduled(t *testing.T) {
+	srv := httptest.NewServer(&testHandler{
+		respHeader: http.StatusOK,
+		respBody:   []byte(`{"_id":"abc123","to":"user@email.com"}`),
+	})
+	defer srv.Close()
+
+	res, err := CancelScheduled("abc123", SetMessageUrl(srv.URL))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, res.Id, "abc123")
+}
+
+func TestRescheduleScheduled(t *testing.T) {
+	srv := httptest.NewServer(&testHandler{
+		respHeader: http.StatusOK,
+		respBody:   []byte(`{"_id":"abc123","send_at":"2026-08-01 12:30:00"}`),
+	})
+	defer srv.Close()
+
+	res, err := RescheduleScheduled("abc123", time.Date(2026, 8, 1, 12, 30, 0, 0, time.UTC), SetMessageUrl(srv.URL))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, res.Id, "abc123")
+	ensure.DeepEqual(t, res.SendAt, "2026-08-01 12:30:00")
+}
+
+func TestScheduleAt(t *testing.T) {
+	at := time.Date(2026, 8, 1, 12, 30, 0, 0, time.UTC)
+	m := Message{}
+	m.ScheduleAt(at)
+	ensure.DeepEqual(t, m.SendAt, at)
+}
+
+func TestSetTracking(t *testing.T) {
+	m := Message{}
+	m.SetTracking(true, false)
+	ensure.True(t, *m.TrackOpens)
+	ensure.True(t, !*m.TrackClicks)
+}
+
+func TestUseIPPool(t *testing.T) {
+	m := Message{}
+	m.UseIPPool("testPool")
+	ensure.DeepEqual(t, m.IPPool, "testPool")
+}
+
+func TestMessageJSONExcludesSendAtAndIPPool(t *testing.T) {
+	m := Message{}
+	m.ScheduleAt(time.Date(2026, 8, 1, 12, 30, 0, 0, time.UTC))
+	m.UseIPPool("testPool")
+
+	raw, err := json.Marshal(&m)
+	ensure.Nil(t, err)
+
+	var fields map[string]interface{}
+	ensure.Nil(t, json.Unmarshal(raw, &fields))
+	_, hasSendAt := fields["send_at"]
+	_, hasIPPool := fields["ip_pool"]
+	ensure.True(t, !hasSendAt)
+	ensure.True(t, !hasIPPool)
+}
+
+func TestSendPayloadIncludesSendAtAndIPPoolAsSiblings(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		bodies <- raw
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	m := &Message{}
+	m.ScheduleAt(time.Date(2026, 8, 1, 12, 30, 0, 0, time.UTC))
+	m.UseIPPool("testPool")
+
+	_, err := m.Send(false, SetMessageUrl(srv.URL))
+	ensure.Nil(t, err)
+	raw := <-bodies
+
+	var fields map[string]interface{}
+	ensure.Nil(t, json.Unmarshal(raw, &fields))
+	ensure.DeepEqual(t, fields["send_at"], "2026-08-01 12:30:00")
+	ensure.DeepEqual(t, fields["ip_pool"], "testPool")
+
+	var msgFields map[string]interface{}
+	msgRaw, err := json.Marshal(fields["message"])
+	ensure.Nil(t, err)
+	ensure.Nil(t, json.Unmarshal(msgRaw, &msgFields))
+	_, hasSendAt := msgFields["send_at"]
+	ensure.True(t, !hasSendAt)
+}
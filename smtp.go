@@ -0,0 +1,292 @@
+package mandrill
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// SMTPMailer delivers messages via a plain SMTP server using net/smtp,
+// instead of Mandrill's API. It exists so applications can fail over to a
+// self-hosted SMTP server when Mandrill is unreachable.
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// NewSMTPMailer returns a SMTPMailer that authenticates with username and
+// password, using AUTH PLAIN when the server supports it and falling back to
+// AUTH LOGIN otherwise.
+func NewSMTPMailer(host string, port int, username, password string) *SMTPMailer {
+	return &SMTPMailer{Host: host, Port: port, Username: username, Password: password}
+}
+
+func (s *SMTPMailer) addr() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+}
+
+func (s *SMTPMailer) auth() smtp.Auth {
+	return &smtpAuth{username: s.Username, password: s.Password, host: s.Host}
+}
+
+// Send implements Mailer by rendering msg into a MIME message and delivering
+// it over SMTP. async is ignored, since net/smtp is always synchronous.
+func (s *SMTPMailer) Send(msg *Message, async bool) ([]*SendResult, error) {
+	raw, err := msg.mimeMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := msg.recipientEmails()
+	if err := smtp.SendMail(s.addr(), s.auth(), msg.FromEmail, recipients, raw); err != nil {
+		return nil, err
+	}
+
+	results := make([]*SendResult, len(recipients))
+	for i, email := range recipients {
+		results[i] = &SendResult{Email: email, Status: "sent"}
+	}
+	return results, nil
+}
+
+// SendTemplate implements Mailer. SMTP has no concept of Mandrill's
+// server-side templates, so this always returns an error.
+func (s *SMTPMailer) SendTemplate(msg *Message, tmpl string, content map[string]string, async bool) ([]*SendResult, error) {
+	return nil, fmt.Errorf("mandrill: SMTPMailer does not support templates")
+}
+
+// smtpAuth implements smtp.Auth, preferring AUTH PLAIN and falling back to
+// AUTH LOGIN for servers that don't advertise PLAIN.
+type smtpAuth struct {
+	username, password, host string
+	chosen                   smtp.Auth
+}
+
+func (a *smtpAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	a.chosen = &loginAuth{username: a.username, password: a.password}
+	for _, m := range server.Auth {
+		if m == "PLAIN" {
+			a.chosen = smtp.PlainAuth("", a.username, a.password, a.host)
+			break
+		}
+	}
+	return a.chosen.Start(server)
+}
+
+func (a *smtpAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	return a.chosen.Next(fromServer, more)
+}
+
+// loginAuth implements the AUTH LOGIN SASL mechanism, which net/smtp does not
+// provide out of the box.
+type loginAuth struct {
+	username, password string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSuffix(string(fromServer), ":")) {
+	case "username":
+		return []byte(a.username), nil
+	case "password":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("mandrill: unexpected AUTH LOGIN prompt %q", fromServer)
+	}
+}
+
+// recipientEmails returns the email address of every recipient, regardless
+// of RecipientType, for use as the SMTP envelope recipients.
+func (msg *Message) recipientEmails() []string {
+	emails := make([]string, len(msg.To))
+	for i, to := range msg.To {
+		emails[i] = to.Email
+	}
+	return emails
+}
+
+// recipientsOfType returns the recipients of msg with the given type, with
+// an empty Type treated as RecipientTo.
+func (msg *Message) recipientsOfType(typ RecipientType) []*To {
+	var out []*To
+	for _, to := range msg.To {
+		t := to.Type
+		if t == "" {
+			t = RecipientTo
+		}
+		if t == typ {
+			out = append(out, to)
+		}
+	}
+	return out
+}
+
+// mimeMessage renders msg as a RFC 822 message suitable for net/smtp.SendMail,
+// with HTML and text alternatives, any inline Images wrapped in
+// multipart/related so HTML referencing them via cid: resolves, any
+// Attachments, and custom headers.
+func (msg *Message) mimeMessage() ([]byte, error) {
+	var buf bytes.Buffer
+
+	header := make(textproto.MIMEHeader)
+	header.Set("From", formatAddress(msg.FromEmail, msg.FromName))
+	header.Set("To", joinAddresses(msg.recipientsOfType(RecipientTo)))
+	if cc := msg.recipientsOfType(RecipientCC); len(cc) > 0 {
+		header.Set("Cc", joinAddresses(cc))
+	}
+	header.Set("Subject", msg.Subject)
+	header.Set("MIME-Version", "1.0")
+	for name, value := range msg.Headers {
+		header.Set(name, value)
+	}
+
+	altBuf := &bytes.Buffer{}
+	aw := multipart.NewWriter(altBuf)
+	if msg.Text != "" {
+		pw, err := aw.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+		if err != nil {
+			return nil, err
+		}
+		pw.Write([]byte(msg.Text))
+	}
+	if msg.HTML != "" {
+		pw, err := aw.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/html; charset=utf-8"}})
+		if err != nil {
+			return nil, err
+		}
+		pw.Write([]byte(msg.HTML))
+	}
+	if err := aw.Close(); err != nil {
+		return nil, err
+	}
+
+	// bodyBuf holds the part that goes alongside the Attachments in the outer
+	// multipart/mixed: the alternative part alone, or the alternative part
+	// wrapped in multipart/related with the inline Images, so HTML referencing
+	// them via cid: resolves.
+	bodyBuf := altBuf
+	bodyContentType := fmt.Sprintf("multipart/alternative; boundary=%s", aw.Boundary())
+	if len(msg.Images) > 0 {
+		relBuf := &bytes.Buffer{}
+		rw := multipart.NewWriter(relBuf)
+
+		altPart, err := rw.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {fmt.Sprintf("multipart/alternative; boundary=%s", aw.Boundary())},
+		})
+		if err != nil {
+			return nil, err
+		}
+		altPart.Write(altBuf.Bytes())
+
+		for _, img := range msg.Images {
+			pw, err := rw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":              {img.Mime},
+				"Content-Transfer-Encoding": {"base64"},
+				"Content-Disposition":       {"inline"},
+				"Content-ID":                {fmt.Sprintf("<%s>", img.Name)},
+			})
+			if err != nil {
+				return nil, err
+			}
+			pw.Write([]byte(wrapBase64(img.Content)))
+		}
+		if err := rw.Close(); err != nil {
+			return nil, err
+		}
+
+		bodyBuf = relBuf
+		bodyContentType = fmt.Sprintf("multipart/related; boundary=%s", rw.Boundary())
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header.Set("Content-Type", fmt.Sprintf("multipart/mixed; boundary=%s", mw.Boundary()))
+	writeHeader(&buf, header)
+
+	bodyPart, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {bodyContentType},
+	})
+	if err != nil {
+		return nil, err
+	}
+	bodyPart.Write(bodyBuf.Bytes())
+
+	for _, a := range msg.Attachments {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {a.Mime},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", a.Name)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		pw.Write([]byte(wrapBase64(a.Content)))
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// formatAddress renders an address as "Name <email>", or just email if name
+// is empty.
+func formatAddress(email, name string) string {
+	if name == "" {
+		return email
+	}
+	return (&mail.Address{Name: name, Address: email}).String()
+}
+
+// joinAddresses renders a list of recipients as a comma-separated address
+// list suitable for a To/Cc header.
+func joinAddresses(tos []*To) string {
+	addrs := make([]string, len(tos))
+	for i, to := range tos {
+		addrs[i] = formatAddress(to.Email, to.Name)
+	}
+	return strings.Join(addrs, ", ")
+}
+
+// writeHeader writes header to buf in "Key: Value\r\n" form, sorted by key
+// for deterministic output, followed by the blank line that ends it.
+func writeHeader(buf *bytes.Buffer, header textproto.MIMEHeader) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			fmt.Fprintf(buf, "%s: %s\r\n", k, v)
+		}
+	}
+	buf.WriteString("\r\n")
+}
+
+// wrapBase64 splits s into 76-character lines, as required for base64 body
+// content in a MIME message.
+func wrapBase64(s string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i += 76 {
+		end := i + 76
+		if end > len(s) {
+			end = len(s)
+		}
+		buf.WriteString(s[i:end])
+		buf.WriteString("\r\n")
+	}
+	return buf.String()
+}
@@ -0,0 +1,69 @@
+package mandrill
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+)
+
+func TestMimeMessageInlineImage(t *testing.T) {
+	m := Message{
+		HTML:      `<p><img src="cid:logo"></p>`,
+		Subject:   "Test Subject",
+		FromEmail: "from@email.com",
+	}
+	m.AddRecipient("to@email.com", "To Name")
+	err := m.AddInlineImage(strings.NewReader("fake image bytes"), "logo", "image/png")
+	ensure.Nil(t, err)
+	m.AddAttachment([]byte("file contents"), "file.txt", "text/plain")
+
+	raw, err := m.mimeMessage()
+	ensure.Nil(t, err)
+	body := string(raw)
+	ensure.True(t, strings.Contains(body, "multipart/related"))
+	ensure.True(t, strings.Contains(body, "Content-ID: <logo>"))
+	ensure.True(t, strings.Contains(body, "Content-Disposition: inline"))
+	ensure.True(t, strings.Contains(body, "filename=\"file.txt\""))
+}
+
+func TestMimeMessage(t *testing.T) {
+	m := Message{
+		HTML:      "<p>Test HTML</p>",
+		Text:      "Test Text",
+		Subject:   "Test Subject",
+		FromEmail: "from@email.com",
+		FromName:  "From Name",
+	}
+	m.AddRecipient("to@email.com", "To Name")
+	m.AddRecipientType("cc@email.com", "Cc Name", RecipientCC)
+	m.AddAttachment([]byte("file contents"), "file.txt", "text/plain")
+
+	raw, err := m.mimeMessage()
+	ensure.Nil(t, err)
+	body := string(raw)
+	ensure.True(t, strings.Contains(body, "Subject: Test Subject"))
+	ensure.True(t, strings.Contains(body, "To: \"To Name\" <to@email.com>"))
+	ensure.True(t, strings.Contains(body, "Cc: \"Cc Name\" <cc@email.com>"))
+	ensure.True(t, strings.Contains(body, "Test HTML"))
+	ensure.True(t, strings.Contains(body, "Test Text"))
+	ensure.True(t, strings.Contains(body, "filename=\"file.txt\""))
+}
+
+func TestRecipientEmails(t *testing.T) {
+	m := Message{}
+	m.AddRecipient("to@email.com", "To Name")
+	m.AddRecipientType("cc@email.com", "Cc Name", RecipientCC)
+	m.AddRecipientType("bcc@email.com", "Bcc Name", RecipientBCC)
+	ensure.DeepEqual(t, m.recipientEmails(), []string{"to@email.com", "cc@email.com", "bcc@email.com"})
+}
+
+func TestLoginAuthNext(t *testing.T) {
+	a := &loginAuth{username: "user", password: "pass"}
+	resp, err := a.Next([]byte("Username:"), true)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, resp, []byte("user"))
+	resp, err = a.Next([]byte("Password:"), true)
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, resp, []byte("pass"))
+}
@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/mostafah/mandrill"
+)
+
+// allEvents lists every event Mux can dispatch, used by Add when no events
+// are given explicitly.
+var allEvents = []string{
+	"send", "deferral", "hard_bounce", "soft_bounce",
+	"open", "click", "spam", "unsub", "reject",
+}
+
+// Info describes a webhook registered with Mandrill, as returned by Add,
+// List, and Delete.
+type Info struct {
+	Id     int      `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// Add registers a new webhook with Mandrill pointing at url, subscribed to
+// events (or every event Mux can dispatch, if events is empty).
+func Add(url string, events []string, opts ...mandrill.Option) (*Info, error) {
+	if len(events) == 0 {
+		events = allEvents
+	}
+	var data struct {
+		Key    string   `json:"key"`
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	data.Key = mandrill.Key
+	data.URL = url
+	data.Events = events
+
+	res := &Info{}
+	if err := mandrill.Do(context.Background(), "/webhooks/add", &data, res, opts...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// List returns every webhook registered with Mandrill.
+func List(opts ...mandrill.Option) ([]*Info, error) {
+	var data struct {
+		Key string `json:"key"`
+	}
+	data.Key = mandrill.Key
+
+	res := make([]*Info, 0)
+	if err := mandrill.Do(context.Background(), "/webhooks/list", &data, &res, opts...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Delete removes the webhook with the given id.
+func Delete(id int, opts ...mandrill.Option) (*Info, error) {
+	var data struct {
+		Key string `json:"key"`
+		Id  int    `json:"id"`
+	}
+	data.Key = mandrill.Key
+	data.Id = id
+
+	res := &Info{}
+	if err := mandrill.Do(context.Background(), "/webhooks/delete", &data, res, opts...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+	"github.com/mostafah/mandrill"
+)
+
+type testHandler struct {
+	respHeader int
+	respBody   []byte
+}
+
+func (h *testHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(h.respHeader)
+	w.Write(h.respBody)
+}
+
+func TestAdd(t *testing.T) {
+	srv := httptest.NewServer(&testHandler{
+		respHeader: http.StatusOK,
+		respBody:   []byte(`{"id":1,"url":"https://example.com/hook","events":["open"]}`),
+	})
+	defer srv.Close()
+
+	res, err := Add("https://example.com/hook", []string{"open"}, mandrill.SetMessageUrl(srv.URL))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, res.Id, 1)
+	ensure.DeepEqual(t, res.URL, "https://example.com/hook")
+}
+
+func TestList(t *testing.T) {
+	srv := httptest.NewServer(&testHandler{
+		respHeader: http.StatusOK,
+		respBody:   []byte(`[{"id":1,"url":"https://example.com/hook","events":["open"]}]`),
+	})
+	defer srv.Close()
+
+	res, err := List(mandrill.SetMessageUrl(srv.URL))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, len(res), 1)
+	ensure.DeepEqual(t, res[0].Id, 1)
+}
+
+func TestDelete(t *testing.T) {
+	srv := httptest.NewServer(&testHandler{
+		respHeader: http.StatusOK,
+		respBody:   []byte(`{"id":1,"url":"https://example.com/hook","events":["open"]}`),
+	})
+	defer srv.Close()
+
+	res, err := Delete(1, mandrill.SetMessageUrl(srv.URL))
+	ensure.Nil(t, err)
+	ensure.DeepEqual(t, res.Id, 1)
+}
@@ -0,0 +1,287 @@
+// Package webhook verifies and parses Mandrill's inbound webhook callbacks.
+//
+// Register an http.Handler for the events you care about and point a
+// Mandrill webhook at it:
+//
+//     mux := webhook.New("https://example.com/mandrill/webhook", "webhook-auth-key")
+//     mux.OnOpen(func(e webhook.OpenEvent) {
+//         log.Printf("%s opened %s", e.Message.Email, e.Message.Id)
+//     })
+//     http.Handle("/mandrill/webhook", mux)
+//
+// URL must match exactly what's configured in Mandrill's settings, since
+// Mandrill signs each request against it. Key is the webhook's own auth key
+// from that same settings page, not the account's API key.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// EventMessage holds the fields common to every event: the message it
+// happened to and the recipient it happened for.
+type EventMessage struct {
+	Id    string `json:"_id"`
+	Email string `json:"email"`
+}
+
+// SendEvent fires when Mandrill accepts a message for delivery.
+type SendEvent struct {
+	Timestamp int64        `json:"ts"`
+	Message   EventMessage `json:"msg"`
+}
+
+// DeferralEvent fires when the receiving server temporarily rejects a
+// message.
+type DeferralEvent struct {
+	Timestamp int64        `json:"ts"`
+	Message   EventMessage `json:"msg"`
+}
+
+// HardBounceEvent fires when a message permanently bounces.
+type HardBounceEvent struct {
+	Timestamp int64        `json:"ts"`
+	Message   EventMessage `json:"msg"`
+}
+
+// SoftBounceEvent fires when a message temporarily bounces.
+type SoftBounceEvent struct {
+	Timestamp int64        `json:"ts"`
+	Message   EventMessage `json:"msg"`
+}
+
+// OpenEvent fires when a recipient opens a message.
+type OpenEvent struct {
+	Timestamp int64        `json:"ts"`
+	Message   EventMessage `json:"msg"`
+	IP        string       `json:"ip"`
+	UserAgent string       `json:"user_agent"`
+}
+
+// ClickEvent fires when a recipient clicks a tracked link in a message.
+type ClickEvent struct {
+	Timestamp int64        `json:"ts"`
+	Message   EventMessage `json:"msg"`
+	IP        string       `json:"ip"`
+	UserAgent string       `json:"user_agent"`
+	URL       string       `json:"url"`
+}
+
+// SpamEvent fires when a recipient reports a message as spam.
+type SpamEvent struct {
+	Timestamp int64        `json:"ts"`
+	Message   EventMessage `json:"msg"`
+}
+
+// UnsubEvent fires when a recipient unsubscribes.
+type UnsubEvent struct {
+	Timestamp int64        `json:"ts"`
+	Message   EventMessage `json:"msg"`
+}
+
+// RejectEvent fires when Mandrill rejects a message outright, without
+// attempting delivery.
+type RejectEvent struct {
+	Timestamp int64        `json:"ts"`
+	Message   EventMessage `json:"msg"`
+	Reason    string       `json:"reject_reason"`
+}
+
+// Mux is an http.Handler that verifies Mandrill's webhook signature and
+// dispatches each event in the request to the callbacks registered for its
+// type.
+type Mux struct {
+	url string
+	key string
+
+	onSend       []func(SendEvent)
+	onDeferral   []func(DeferralEvent)
+	onHardBounce []func(HardBounceEvent)
+	onSoftBounce []func(SoftBounceEvent)
+	onOpen       []func(OpenEvent)
+	onClick      []func(ClickEvent)
+	onSpam       []func(SpamEvent)
+	onUnsub      []func(UnsubEvent)
+	onReject     []func(RejectEvent)
+}
+
+// New returns a Mux that verifies requests as having come from the webhook
+// at url, authenticated with key.
+func New(url, key string) *Mux {
+	return &Mux{url: url, key: key}
+}
+
+// OnSend registers f to be called for every SendEvent.
+func (m *Mux) OnSend(f func(SendEvent)) { m.onSend = append(m.onSend, f) }
+
+// OnDeferral registers f to be called for every DeferralEvent.
+func (m *Mux) OnDeferral(f func(DeferralEvent)) { m.onDeferral = append(m.onDeferral, f) }
+
+// OnHardBounce registers f to be called for every HardBounceEvent.
+func (m *Mux) OnHardBounce(f func(HardBounceEvent)) { m.onHardBounce = append(m.onHardBounce, f) }
+
+// OnSoftBounce registers f to be called for every SoftBounceEvent.
+func (m *Mux) OnSoftBounce(f func(SoftBounceEvent)) { m.onSoftBounce = append(m.onSoftBounce, f) }
+
+// OnOpen registers f to be called for every OpenEvent.
+func (m *Mux) OnOpen(f func(OpenEvent)) { m.onOpen = append(m.onOpen, f) }
+
+// OnClick registers f to be called for every ClickEvent.
+func (m *Mux) OnClick(f func(ClickEvent)) { m.onClick = append(m.onClick, f) }
+
+// OnSpam registers f to be called for every SpamEvent.
+func (m *Mux) OnSpam(f func(SpamEvent)) { m.onSpam = append(m.onSpam, f) }
+
+// OnUnsub registers f to be called for every UnsubEvent.
+func (m *Mux) OnUnsub(f func(UnsubEvent)) { m.onUnsub = append(m.onUnsub, f) }
+
+// OnReject registers f to be called for every RejectEvent.
+func (m *Mux) OnReject(f func(RejectEvent)) { m.onReject = append(m.onReject, f) }
+
+// ServeHTTP verifies r's signature and dispatches the events it carries. It
+// responds 401 on a signature mismatch and 400 if the request can't be
+// parsed.
+func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !m.verify(r) {
+		http.Error(w, "mandrill: invalid webhook signature", http.StatusUnauthorized)
+		return
+	}
+
+	var events []json.RawMessage
+	if err := json.Unmarshal([]byte(r.PostForm.Get("mandrill_events")), &events); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, raw := range events {
+		if err := m.dispatch(raw); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// verify recomputes Mandrill's request signature and compares it against
+// the X-Mandrill-Signature header. The signature base string is m.url
+// followed by r's POST parameter keys, sorted alphabetically, each followed
+// by its value.
+func (m *Mux) verify(r *http.Request) bool {
+	sig := r.Header.Get("X-Mandrill-Signature")
+	if sig == "" {
+		return false
+	}
+
+	keys := make([]string, 0, len(r.PostForm))
+	for k := range r.PostForm {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	base := m.url
+	for _, k := range keys {
+		base += k + r.PostForm.Get(k)
+	}
+
+	mac := hmac.New(sha1.New, []byte(m.key))
+	mac.Write([]byte(base))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return subtle.ConstantTimeCompare([]byte(sig), []byte(expected)) == 1
+}
+
+// dispatch decodes a single event from raw and calls every callback
+// registered for its type.
+func (m *Mux) dispatch(raw json.RawMessage) error {
+	var head struct {
+		Event string `json:"event"`
+	}
+	if err := json.Unmarshal(raw, &head); err != nil {
+		return err
+	}
+
+	switch head.Event {
+	case "send":
+		var e SendEvent
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return err
+		}
+		for _, f := range m.onSend {
+			f(e)
+		}
+	case "deferral":
+		var e DeferralEvent
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return err
+		}
+		for _, f := range m.onDeferral {
+			f(e)
+		}
+	case "hard_bounce":
+		var e HardBounceEvent
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return err
+		}
+		for _, f := range m.onHardBounce {
+			f(e)
+		}
+	case "soft_bounce":
+		var e SoftBounceEvent
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return err
+		}
+		for _, f := range m.onSoftBounce {
+			f(e)
+		}
+	case "open":
+		var e OpenEvent
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return err
+		}
+		for _, f := range m.onOpen {
+			f(e)
+		}
+	case "click":
+		var e ClickEvent
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return err
+		}
+		for _, f := range m.onClick {
+			f(e)
+		}
+	case "spam":
+		var e SpamEvent
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return err
+		}
+		for _, f := range m.onSpam {
+			f(e)
+		}
+	case "unsub":
+		var e UnsubEvent
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return err
+		}
+		for _, f := range m.onUnsub {
+			f(e)
+		}
+	case "reject":
+		var e RejectEvent
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return err
+		}
+		for _, f := range m.onReject {
+			f(e)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/facebookgo/ensure"
+)
+
+func sign(webhookURL, key string, form url.Values) string {
+	keys := make([]string, 0, len(form))
+	for k := range form {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	base := webhookURL
+	for _, k := range keys {
+		base += k + form.Get(k)
+	}
+
+	mac := hmac.New(sha1.New, []byte(key))
+	mac.Write([]byte(base))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestMuxDispatchesOpenEvent(t *testing.T) {
+	const webhookURL = "https://example.com/hook"
+	const key = "testKey"
+
+	var got OpenEvent
+	mux := New(webhookURL, key)
+	mux.OnOpen(func(e OpenEvent) { got = e })
+
+	events := `[{"event":"open","ts":1600000000,"msg":{"_id":"abc123","email":"test@test.com"},"ip":"1.2.3.4","user_agent":"test-agent"}]`
+	form := url.Values{"mandrill_events": {events}}
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Mandrill-Signature", sign(webhookURL, key, form))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	ensure.DeepEqual(t, rec.Code, http.StatusOK)
+	ensure.DeepEqual(t, got.Message.Id, "abc123")
+	ensure.DeepEqual(t, got.Message.Email, "test@test.com")
+	ensure.DeepEqual(t, got.IP, "1.2.3.4")
+}
+
+func TestMuxRejectsBadSignature(t *testing.T) {
+	mux := New("https://example.com/hook", "testKey")
+
+	form := url.Values{"mandrill_events": {"[]"}}
+	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Mandrill-Signature", "bogus")
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	ensure.DeepEqual(t, rec.Code, http.StatusUnauthorized)
+}